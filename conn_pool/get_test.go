@@ -0,0 +1,112 @@
+package conn_pool
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeConn is a minimal io.Closer used across this package's tests.
+type fakeConn struct {
+	closed int32
+}
+
+func (c *fakeConn) Close() error {
+	atomic.AddInt32(&c.closed, 1)
+	return nil
+}
+
+func TestNewConnPoolLegacyConstructorIsFIFO(t *testing.T) {
+	p := NewConnPool("t", 2, 2)
+	p.New = func() (io.Closer, error) { return &fakeConn{}, nil }
+	p.Ping = func(io.Closer) error { return nil }
+
+	c1, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get c1: %v", err)
+	}
+	c2, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get c2: %v", err)
+	}
+	p.Release(c1)
+	p.Release(c2)
+
+	got, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got != c1 {
+		t.Fatalf("NewConnPool must preserve the legacy FIFO behavior and reuse the oldest conn (c1) first")
+	}
+}
+
+func TestGetPoolFIFOOptionIsFIFO(t *testing.T) {
+	p := NewConnPoolWithOptions("t", 2, 2, Options{PoolFIFO: true})
+	p.New = func() (io.Closer, error) { return &fakeConn{}, nil }
+	p.Ping = func(io.Closer) error { return nil }
+
+	c1, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get c1: %v", err)
+	}
+	c2, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get c2: %v", err)
+	}
+	p.Release(c1)
+	p.Release(c2)
+
+	got, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got != c1 {
+		t.Fatalf("PoolFIFO=true should reuse the oldest conn (c1) first")
+	}
+}
+
+func TestGetDefaultIsLIFO(t *testing.T) {
+	p := NewConnPoolWithOptions("t", 2, 2, Options{})
+	p.New = func() (io.Closer, error) { return &fakeConn{}, nil }
+	p.Ping = func(io.Closer) error { return nil }
+
+	c1, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get c1: %v", err)
+	}
+	c2, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get c2: %v", err)
+	}
+	p.Release(c1)
+	p.Release(c2)
+
+	got, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got != c2 {
+		t.Fatalf("default PoolFIFO=false should reuse the most recently released conn (c2) first")
+	}
+}
+
+func TestGetPoolTimeout(t *testing.T) {
+	p := NewConnPoolWithOptions("t", 1, 1, Options{PoolTimeout: 20 * time.Millisecond})
+	p.New = func() (io.Closer, error) { return &fakeConn{}, nil }
+	p.Ping = func(io.Closer) error { return nil }
+
+	if _, err := p.Get(context.Background()); err != nil {
+		t.Fatalf("first Get should succeed: %v", err)
+	}
+
+	_, err := p.Get(context.Background())
+	if err != ErrPoolTimeout {
+		t.Fatalf("expected ErrPoolTimeout on a saturated pool, got %v", err)
+	}
+	if p.Stats().Timeouts != 1 {
+		t.Fatalf("expected Stats().Timeouts to record the timeout")
+	}
+}
@@ -1,21 +1,28 @@
 package conn_pool
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
-	MAX_CONN_ERROR = "Maximum connections reached"
-	LOG_TAG        = "[conn_pool]"
+	MAX_CONN_ERROR     = "Maximum connections reached"
+	POOL_TIMEOUT_ERROR = "timed out waiting for a free connection"
+	RATE_LIMITED_ERROR = "dial rate limit exceeded"
+	LOG_TAG            = "[conn_pool]"
 )
 
 var (
-	debug      debugging = false
-	ErrMaxConn           = errors.New(MAX_CONN_ERROR)
+	debug          debugging = false
+	ErrMaxConn               = errors.New(MAX_CONN_ERROR)
+	ErrPoolTimeout           = errors.New(POOL_TIMEOUT_ERROR)
+	ErrRateLimited           = errors.New(RATE_LIMITED_ERROR)
 )
 
 type debugging bool
@@ -34,6 +41,111 @@ func EnableDebug(f bool) {
 	}
 }
 
+// Options configures the pool-wait behavior of a ConnPool. With the zero
+// value, Get on a saturated pool waits (subject to its context) for a
+// connection to be released instead of returning ErrMaxConn.
+type Options struct {
+	// PoolTimeout is how long Get waits for a connection to be released
+	// before giving up with ErrPoolTimeout. Zero means wait indefinitely
+	// (subject to the context passed to Get).
+	PoolTimeout time.Duration
+
+	// PoolFIFO selects which idle connection Get reuses first. true
+	// reuses the oldest idle connection (FIFO); false (the default)
+	// reuses the most recently released one (LIFO), which keeps fewer
+	// connections warm under light load.
+	PoolFIFO bool
+
+	// IdleTimeout closes idle connections that have sat unused in the pool
+	// longer than this. Zero disables idle reaping.
+	IdleTimeout time.Duration
+
+	// MaxConnLifetime closes connections once they've existed this long,
+	// even if still in active rotation. Zero disables lifetime reaping.
+	MaxConnLifetime time.Duration
+
+	// IdleCheckFrequency is how often the janitor scans the pool for stale
+	// connections. Defaults to time.Minute when IdleTimeout or
+	// MaxConnLifetime is set and this is left zero.
+	IdleCheckFrequency time.Duration
+
+	// DialRateLimit caps how many new connections Get may dial per second.
+	// Zero disables rate limiting. Once the bucket is empty, Get returns
+	// ErrRateLimited instead of dialing.
+	DialRateLimit float64
+
+	// DialErrorQuarantine is how long Get remembers the last dial error and
+	// fails fast with it instead of attempting another dial. Zero disables
+	// quarantine.
+	DialErrorQuarantine time.Duration
+}
+
+// dialLimiter is a simple token-bucket limiter gating how often Get may
+// call New to open a fresh connection.
+type dialLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newDialLimiter(rate float64) *dialLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	burst := rate
+	if burst < 1 {
+		burst = 1
+	}
+	return &dialLimiter{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+func (l *dialLimiter) Allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens -= 1
+	return true
+}
+
+// dialErr is stashed in ConnPool.lastDialErr so concurrent callers can fail
+// fast on a recent dial failure instead of piling onto a flapping backend.
+type dialErr struct {
+	err error
+	at  time.Time
+}
+
+// pooledConn wraps a connection sitting idle in the free list so the
+// janitor can tell how long it's been idle and how old it is.
+type pooledConn struct {
+	closer    io.Closer
+	usedAt    time.Time
+	createdAt time.Time
+}
+
+// Stats is a point-in-time snapshot of pool activity, suitable for wiring
+// into Prometheus/OpenTelemetry without holding the pool's mutex.
+type Stats struct {
+	Hits     uint64 // number of times Get reused a free connection
+	Misses   uint64 // number of times Get had to dial a new connection
+	Timeouts uint64 // number of times Get gave up with ErrPoolTimeout
+
+	TotalConns uint32 // connections currently open (idle + checked out)
+	IdleConns  uint32 // connections currently idle in the free list
+	StaleConns uint64 // connections closed by the janitor over the pool's lifetime
+}
+
 // ConnPool manages the life cycle of connections
 type ConnPool struct {
 	sync.Mutex
@@ -48,38 +160,221 @@ type ConnPool struct {
 	MaxConns int
 	MaxIdle  int
 
-	conns int
-	free  []io.Closer
+	Options
+
+	hits       uint64
+	misses     uint64
+	timeouts   uint64
+	staleConns uint64
+
+	conns        int
+	free         []pooledConn
+	created      map[io.Closer]time.Time
+	sem          chan struct{}
+	onStaleClose func(io.Closer)
+	stopJanitor  chan struct{}
+	closeOnce    sync.Once
+
+	limiter     *dialLimiter
+	lastDialErr atomic.Value
 }
 
+// NewConnPool preserves the pool's pre-Options behavior of reusing the
+// oldest free connection first (FIFO); callers that want the newer LIFO
+// default should use NewConnPoolWithOptions directly.
 func NewConnPool(name string, max_conns int, max_idle int) *ConnPool {
-	return &ConnPool{
+	return NewConnPoolWithOptions(name, max_conns, max_idle, Options{PoolFIFO: true})
+}
+
+// NewConnPoolWithOptions is like NewConnPool but also configures pool-wait
+// behavior via opts.
+func NewConnPoolWithOptions(name string, max_conns int, max_idle int, opts Options) *ConnPool {
+	if opts.IdleCheckFrequency == 0 && (opts.IdleTimeout > 0 || opts.MaxConnLifetime > 0) {
+		opts.IdleCheckFrequency = time.Minute
+	}
+
+	p := &ConnPool{
 		Name:     name,
 		MaxConns: max_conns,
 		MaxIdle:  max_idle,
+		Options:  opts,
+		created:  make(map[io.Closer]time.Time),
+		sem:      make(chan struct{}, max_conns),
+		limiter:  newDialLimiter(opts.DialRateLimit),
 	}
+
+	if opts.IdleCheckFrequency > 0 {
+		p.stopJanitor = make(chan struct{})
+		go p.janitor()
+	}
+
+	return p
 }
 
-func (p *ConnPool) Get() (conn io.Closer, err error) {
+// SetOnStaleClose registers a callback invoked with the raw connection
+// whenever the janitor closes it for being idle or past MaxConnLifetime.
+func (p *ConnPool) SetOnStaleClose(f func(io.Closer)) {
+	p.Lock()
+	p.onStaleClose = f
+	p.Unlock()
+}
+
+func (p *ConnPool) janitor() {
+	ticker := time.NewTicker(p.IdleCheckFrequency)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.reapStale()
+		case <-p.stopJanitor:
+			return
+		}
+	}
+}
+
+func (p *ConnPool) reapStale() {
+	now := time.Now()
+
+	p.Lock()
+	kept := p.free[:0]
+	var stale []pooledConn
+	for _, pc := range p.free {
+		if (p.IdleTimeout > 0 && now.Sub(pc.usedAt) > p.IdleTimeout) ||
+			(p.MaxConnLifetime > 0 && now.Sub(pc.createdAt) > p.MaxConnLifetime) {
+			stale = append(stale, pc)
+			delete(p.created, pc.closer)
+			p.conns -= 1
+		} else {
+			kept = append(kept, pc)
+		}
+	}
+	p.free = kept
+	onStaleClose := p.onStaleClose
+	p.Unlock()
+
+	for _, pc := range stale {
+		debug.Printf("%v reap stale conn %v, pool %v", LOG_TAG, pc.closer, p)
+		pc.closer.Close()
+		atomic.AddUint64(&p.staleConns, 1)
+		if onStaleClose != nil {
+			onStaleClose(pc.closer)
+		}
+	}
+}
+
+// waitTurn reserves one of MaxConns checkout slots, blocking until one is
+// free, PoolTimeout elapses, or ctx is done.
+func (p *ConnPool) waitTurn(ctx context.Context) error {
+	select {
+	case p.sem <- struct{}{}:
+		return nil
+	default:
+	}
+
+	var timeout <-chan time.Time
+	if p.PoolTimeout > 0 {
+		timer := time.NewTimer(p.PoolTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case p.sem <- struct{}{}:
+		return nil
+	case <-timeout:
+		atomic.AddUint64(&p.timeouts, 1)
+		debug.Printf("%v pool wait timeout, pool %v", LOG_TAG, p)
+		return ErrPoolTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *ConnPool) freeTurn() {
+	select {
+	case <-p.sem:
+	default:
+	}
+}
+
+// quarantinedErr returns the last dial error if it's still within
+// DialErrorQuarantine, so callers fail fast instead of piling onto a
+// flapping backend.
+func (p *ConnPool) quarantinedErr() error {
+	if p.DialErrorQuarantine <= 0 {
+		return nil
+	}
+	de, ok := p.lastDialErr.Load().(dialErr)
+	if !ok || time.Since(de.at) > p.DialErrorQuarantine {
+		return nil
+	}
+	return de.err
+}
+
+func (p *ConnPool) setDialErr(err error) {
+	if p.DialErrorQuarantine > 0 {
+		p.lastDialErr.Store(dialErr{err: err, at: time.Now()})
+	}
+}
+
+func (p *ConnPool) Get(ctx context.Context) (conn io.Closer, err error) {
 	p.Lock()
 	if p.conns >= p.MaxConns && len(p.free) == 0 {
 		p.Unlock()
-		debug.Printf("%v max conn reached, pool %v", LOG_TAG, p)
-		return nil, ErrMaxConn
+		if err = p.waitTurn(ctx); err != nil {
+			return nil, err
+		}
+		p.Lock()
+	} else {
+		select {
+		case p.sem <- struct{}{}:
+		default:
+			p.Unlock()
+			if err = p.waitTurn(ctx); err != nil {
+				return nil, err
+			}
+			p.Lock()
+		}
 	}
 
 	new_conn := false
 	if len(p.free) > 0 {
-		// return the first free connection in the pool
-		conn = p.free[0]
-		p.free = p.free[1:]
+		var pc pooledConn
+		if p.PoolFIFO {
+			// return the oldest free connection in the pool (FIFO)
+			pc = p.free[0]
+			p.free = p.free[1:]
+		} else {
+			// return the most recently released connection (LIFO, default)
+			last := len(p.free) - 1
+			pc = p.free[last]
+			p.free = p.free[:last]
+		}
+		conn = pc.closer
+		atomic.AddUint64(&p.hits, 1)
 	} else {
+		if qerr := p.quarantinedErr(); qerr != nil {
+			p.Unlock()
+			p.freeTurn()
+			return nil, qerr
+		}
+		if p.limiter != nil && !p.limiter.Allow() {
+			p.Unlock()
+			p.freeTurn()
+			return nil, ErrRateLimited
+		}
+
 		conn, err = p.New()
 		if err != nil {
+			p.setDialErr(err)
 			p.Unlock()
+			p.freeTurn()
 			return nil, err
 		}
 		new_conn = true
+		p.created[conn] = time.Now()
+		atomic.AddUint64(&p.misses, 1)
 	}
 	p.Unlock()
 
@@ -90,7 +385,9 @@ func (p *ConnPool) Get() (conn io.Closer, err error) {
 		if !new_conn && p.conns > 0 {
 			p.conns -= 1
 		}
+		delete(p.created, conn)
 		p.Unlock()
+		p.freeTurn()
 		conn.Close()
 		return nil, err
 	}
@@ -112,12 +409,18 @@ func (p *ConnPool) Release(conn io.Closer) error {
 	if len(p.free) >= p.MaxIdle {
 		debug.Printf("%v auto close %v, pool %v", LOG_TAG, conn, p)
 		p.conns -= 1
+		delete(p.created, conn)
 	} else {
-		p.free = append(p.free, conn)
+		p.free = append(p.free, pooledConn{
+			closer:    conn,
+			usedAt:    time.Now(),
+			createdAt: p.created[conn],
+		})
 	}
 	debug.Printf("%v release %v, pool %v", LOG_TAG, conn, p)
 
 	p.Unlock()
+	p.freeTurn()
 	return nil
 }
 
@@ -129,23 +432,79 @@ func (p *ConnPool) CloseClean(conn io.Closer) error {
 	if p.conns > 0 {
 		p.conns -= 1
 	}
+	delete(p.created, conn)
 	debug.Printf("%v closeClean %v, pool %v", LOG_TAG, conn, p)
 	p.Unlock()
+	p.freeTurn()
 
 	return nil
 }
 
+// Destroy closes every idle connection in the pool. It does not stop the
+// janitor goroutine; use Close for that.
 func (p *ConnPool) Destroy() {
 	p.Lock()
 	defer p.Unlock()
 
-	for _, conn := range p.free {
-		if conn != nil {
-			debug.Printf("%v destroy %v, pool %v", LOG_TAG, conn, p)
-			conn.Close()
+	for _, pc := range p.free {
+		if pc.closer != nil {
+			debug.Printf("%v destroy %v, pool %v", LOG_TAG, pc.closer, p)
+			pc.closer.Close()
+		}
+		delete(p.created, pc.closer)
+		if p.conns > 0 {
+			p.conns -= 1
 		}
 	}
-	p = nil
+	p.free = nil
+}
+
+// Close stops the janitor goroutine (if running) and closes every idle
+// connection in the pool. It is safe to call more than once.
+func (p *ConnPool) Close() error {
+	p.closeOnce.Do(func() {
+		if p.stopJanitor != nil {
+			close(p.stopJanitor)
+		}
+		p.Destroy()
+	})
+	return nil
+}
+
+// Len returns the number of connections currently open, idle or checked
+// out, without requiring callers to hold the pool's mutex.
+func (p *ConnPool) Len() int {
+	p.Lock()
+	n := p.conns
+	p.Unlock()
+	return n
+}
+
+// IdleLen returns the number of connections currently idle in the pool.
+func (p *ConnPool) IdleLen() int {
+	p.Lock()
+	n := len(p.free)
+	p.Unlock()
+	return n
+}
+
+// Stats returns a snapshot of the pool's cumulative and point-in-time
+// counters.
+func (p *ConnPool) Stats() *Stats {
+	p.Lock()
+	total := p.conns
+	idle := len(p.free)
+	p.Unlock()
+
+	return &Stats{
+		Hits:     atomic.LoadUint64(&p.hits),
+		Misses:   atomic.LoadUint64(&p.misses),
+		Timeouts: atomic.LoadUint64(&p.timeouts),
+
+		TotalConns: uint32(total),
+		IdleConns:  uint32(idle),
+		StaleConns: atomic.LoadUint64(&p.staleConns),
+	}
 }
 
 func (p *ConnPool) String() string {
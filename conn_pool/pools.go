@@ -0,0 +1,136 @@
+package conn_pool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+var ErrPoolsClosed = errors.New("rpool: Pools is closed")
+
+// Pools manages one ConnPool per address, created lazily on first use and
+// sharing the same Options. It lets callers pool connections to a fleet of
+// backends without instantiating and tracking a ConnPool per host
+// themselves.
+type Pools struct {
+	sync.Mutex
+
+	// New dials a fresh connection for the given address.
+	New func(addr string) (io.Closer, error)
+
+	// Ping validates a connection fetched from the given address's pool.
+	Ping func(addr string, conn io.Closer) error
+
+	MaxConns int
+	MaxIdle  int
+	Options  Options
+
+	pools  map[string]*ConnPool
+	closed bool
+}
+
+func NewPools(max_conns int, max_idle int, opts Options) *Pools {
+	return &Pools{
+		MaxConns: max_conns,
+		MaxIdle:  max_idle,
+		Options:  opts,
+		pools:    make(map[string]*ConnPool),
+	}
+}
+
+// pool returns the ConnPool for addr, creating it on first use. It refuses
+// to create new pools once Close has run.
+func (ps *Pools) pool(addr string) (*ConnPool, error) {
+	ps.Lock()
+	defer ps.Unlock()
+
+	if ps.closed {
+		return nil, ErrPoolsClosed
+	}
+
+	p, ok := ps.pools[addr]
+	if !ok {
+		p = NewConnPoolWithOptions(addr, ps.MaxConns, ps.MaxIdle, ps.Options)
+		p.New = func() (io.Closer, error) { return ps.New(addr) }
+		p.Ping = func(conn io.Closer) error { return ps.Ping(addr, conn) }
+		ps.pools[addr] = p
+	}
+	return p, nil
+}
+
+// lookup returns the existing ConnPool for addr, or nil if none has been
+// created yet (or ever will be, once closed). Unlike pool, it never
+// creates one.
+func (ps *Pools) lookup(addr string) *ConnPool {
+	ps.Lock()
+	defer ps.Unlock()
+	return ps.pools[addr]
+}
+
+func (ps *Pools) Get(ctx context.Context, addr string) (io.Closer, error) {
+	p, err := ps.pool(addr)
+	if err != nil {
+		return nil, err
+	}
+	return p.Get(ctx)
+}
+
+// Release returns conn to addr's pool. If no pool exists for addr (it was
+// never Get from, or Pools has been closed), conn is closed directly
+// instead of spinning up a fresh, never-used pool.
+func (ps *Pools) Release(addr string, conn io.Closer) error {
+	if p := ps.lookup(addr); p != nil {
+		return p.Release(conn)
+	}
+	if conn != nil {
+		conn.Close()
+	}
+	return nil
+}
+
+// CloseClean discards conn instead of returning it to addr's pool. Like
+// Release, it closes conn directly if no pool exists for addr.
+func (ps *Pools) CloseClean(addr string, conn io.Closer) error {
+	if p := ps.lookup(addr); p != nil {
+		return p.CloseClean(conn)
+	}
+	if conn != nil {
+		conn.Close()
+	}
+	return nil
+}
+
+// Stats returns a snapshot of every per-address pool's Stats, keyed by
+// address.
+func (ps *Pools) Stats() map[string]*Stats {
+	ps.Lock()
+	defer ps.Unlock()
+
+	stats := make(map[string]*Stats, len(ps.pools))
+	for addr, p := range ps.pools {
+		stats[addr] = p.Stats()
+	}
+	return stats
+}
+
+// Close stops every per-address pool's janitor and closes its idle
+// connections. It also marks Pools closed so any Get racing with Close
+// fails with ErrPoolsClosed instead of resurrecting a pool that will never
+// be cleaned up. It is safe to call more than once.
+func (ps *Pools) Close() error {
+	ps.Lock()
+	if ps.closed {
+		ps.Unlock()
+		return nil
+	}
+	ps.closed = true
+	pools := ps.pools
+	ps.pools = make(map[string]*ConnPool)
+	ps.Unlock()
+
+	for _, p := range pools {
+		p.Close()
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package conn_pool
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPoolsGetReleaseRoundTrip(t *testing.T) {
+	ps := NewPools(2, 2, Options{})
+	ps.New = func(addr string) (io.Closer, error) { return &fakeConn{}, nil }
+	ps.Ping = func(addr string, conn io.Closer) error { return nil }
+
+	conn, err := ps.Get(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := ps.Release("a", conn); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+
+	stats := ps.Stats()
+	if stats["a"] == nil || stats["a"].IdleConns != 1 {
+		t.Fatalf(`expected address "a" to have one idle conn, got %+v`, stats)
+	}
+}
+
+func TestPoolsReleaseUnknownAddrClosesConnWithoutCreatingPool(t *testing.T) {
+	ps := NewPools(2, 2, Options{})
+	ps.New = func(addr string) (io.Closer, error) { return &fakeConn{}, nil }
+	ps.Ping = func(addr string, conn io.Closer) error { return nil }
+
+	fc := &fakeConn{}
+	if err := ps.Release("never-used", fc); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if atomic.LoadInt32(&fc.closed) != 1 {
+		t.Fatalf("expected a stray Release to close the conn directly")
+	}
+	if _, ok := ps.Stats()["never-used"]; ok {
+		t.Fatalf("Release must not create a pool for an address that was never Get from")
+	}
+}
+
+func TestPoolsCloseRejectsFurtherPoolCreation(t *testing.T) {
+	ps := NewPools(2, 2, Options{})
+	ps.New = func(addr string) (io.Closer, error) { return &fakeConn{}, nil }
+	ps.Ping = func(addr string, conn io.Closer) error { return nil }
+
+	if err := ps.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := ps.Get(context.Background(), "a"); err != ErrPoolsClosed {
+		t.Fatalf("expected ErrPoolsClosed after Close, got %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package conn_pool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestDialRateLimitRejectsBurst(t *testing.T) {
+	p := NewConnPoolWithOptions("t", 10, 10, Options{DialRateLimit: 1})
+	p.New = func() (io.Closer, error) { return &fakeConn{}, nil }
+	p.Ping = func(io.Closer) error { return nil }
+
+	if _, err := p.Get(context.Background()); err != nil {
+		t.Fatalf("first dial should be allowed by a fresh token bucket: %v", err)
+	}
+	if _, err := p.Get(context.Background()); err != ErrRateLimited {
+		t.Fatalf("expected ErrRateLimited on an immediate second dial, got %v", err)
+	}
+}
+
+func TestDialErrorQuarantineFailsFastWithoutRedialing(t *testing.T) {
+	wantErr := errors.New("dial boom")
+	calls := 0
+	p := NewConnPoolWithOptions("t", 10, 10, Options{DialErrorQuarantine: 50 * time.Millisecond})
+	p.New = func() (io.Closer, error) {
+		calls++
+		return nil, wantErr
+	}
+	p.Ping = func(io.Closer) error { return nil }
+
+	if _, err := p.Get(context.Background()); err != wantErr {
+		t.Fatalf("expected the dial error, got %v", err)
+	}
+	if _, err := p.Get(context.Background()); err != wantErr {
+		t.Fatalf("expected the quarantined error on the second Get, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected New to be called once while quarantined, got %d calls", calls)
+	}
+}
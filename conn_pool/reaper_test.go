@@ -0,0 +1,72 @@
+package conn_pool
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdleReaperClosesStaleConns(t *testing.T) {
+	p := NewConnPoolWithOptions("t", 2, 2, Options{
+		IdleTimeout:        10 * time.Millisecond,
+		IdleCheckFrequency: 5 * time.Millisecond,
+	})
+	p.New = func() (io.Closer, error) { return &fakeConn{}, nil }
+	p.Ping = func(io.Closer) error { return nil }
+	defer p.Close()
+
+	conn, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	p.Release(conn)
+
+	deadline := time.After(500 * time.Millisecond)
+	for {
+		if p.IdleLen() == 0 && p.Stats().StaleConns >= 1 {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("janitor did not reap the idle conn in time: stats=%+v idle=%d", p.Stats(), p.IdleLen())
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+
+	if p.Len() != 0 {
+		t.Fatalf("expected Len()==0 after reaping the only conn, got %d", p.Len())
+	}
+	if atomic.LoadInt32(&conn.(*fakeConn).closed) != 1 {
+		t.Fatalf("expected the reaped conn to have been closed")
+	}
+}
+
+func TestSetOnStaleCloseIsInvoked(t *testing.T) {
+	p := NewConnPoolWithOptions("t", 2, 2, Options{
+		IdleTimeout:        10 * time.Millisecond,
+		IdleCheckFrequency: 5 * time.Millisecond,
+	})
+	p.New = func() (io.Closer, error) { return &fakeConn{}, nil }
+	p.Ping = func(io.Closer) error { return nil }
+	defer p.Close()
+
+	notified := make(chan io.Closer, 1)
+	p.SetOnStaleClose(func(c io.Closer) { notified <- c })
+
+	conn, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	p.Release(conn)
+
+	select {
+	case got := <-notified:
+		if got != conn {
+			t.Fatalf("SetOnStaleClose callback received the wrong conn")
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("SetOnStaleClose callback was never invoked")
+	}
+}
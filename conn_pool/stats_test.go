@@ -0,0 +1,40 @@
+package conn_pool
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestStatsHitsMissesAndLen(t *testing.T) {
+	p := NewConnPool("t", 2, 2)
+	p.New = func() (io.Closer, error) { return &fakeConn{}, nil }
+	p.Ping = func(io.Closer) error { return nil }
+
+	conn, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if s := p.Stats(); s.Misses != 1 || s.Hits != 0 {
+		t.Fatalf("expected 1 miss and 0 hits after the first Get, got %+v", s)
+	}
+	if p.Len() != 1 || p.IdleLen() != 0 {
+		t.Fatalf("expected Len()==1, IdleLen()==0 while checked out, got %d/%d", p.Len(), p.IdleLen())
+	}
+
+	p.Release(conn)
+	if p.IdleLen() != 1 {
+		t.Fatalf("expected IdleLen()==1 after Release, got %d", p.IdleLen())
+	}
+
+	if _, err := p.Get(context.Background()); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	s := p.Stats()
+	if s.Hits != 1 || s.Misses != 1 {
+		t.Fatalf("expected 1 hit and 1 miss after reusing the released conn, got %+v", s)
+	}
+	if s.TotalConns != 1 {
+		t.Fatalf("expected TotalConns==1, got %d", s.TotalConns)
+	}
+}
@@ -0,0 +1,156 @@
+package conn_pool
+
+import (
+	"io"
+	"sync"
+)
+
+// streamConn tracks how many logical streams are currently multiplexed
+// over one physical connection.
+type streamConn struct {
+	closer  io.Closer
+	streams int
+}
+
+// StreamPool pools connections that multiplex many logical requests over
+// one physical connection, such as HTTP/2, gRPC or QUIC transports. Get
+// prefers an existing connection with spare stream capacity and only dials
+// a new one once every existing connection is saturated. A connection is
+// never checked out exclusively: Release decrements its stream counter,
+// moving it to the idle list once the count reaches zero, or back to the
+// busy list once a saturated connection has spare capacity again.
+type StreamPool struct {
+	sync.Mutex
+
+	// New is used to create a new connection when every existing one is
+	// saturated (streams == MaxStreams).
+	New func() (io.Closer, error)
+
+	// MaxStreams is how many concurrent logical streams a single
+	// connection may carry.
+	MaxStreams int
+
+	entries map[io.Closer]*streamConn
+	idle    []*streamConn // streams == 0
+	busy    []*streamConn // 0 < streams < MaxStreams
+}
+
+func NewStreamPool(max_streams int) *StreamPool {
+	return &StreamPool{
+		MaxStreams: max_streams,
+		entries:    make(map[io.Closer]*streamConn),
+	}
+}
+
+// Get returns a connection with room for one more stream, reusing a
+// partially-loaded connection when one exists and only dialing a new
+// connection once every existing one is saturated.
+func (p *StreamPool) Get() (conn io.Closer, err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	var sc *streamConn
+	if len(p.busy) > 0 {
+		sc = p.busy[len(p.busy)-1]
+		sc.streams += 1
+		if sc.streams >= p.MaxStreams {
+			p.busy = p.busy[:len(p.busy)-1]
+		}
+		return sc.closer, nil
+	}
+
+	if len(p.idle) > 0 {
+		last := len(p.idle) - 1
+		sc = p.idle[last]
+		p.idle = p.idle[:last]
+	} else {
+		conn, err = p.New()
+		if err != nil {
+			return nil, err
+		}
+		sc = &streamConn{closer: conn}
+		p.entries[conn] = sc
+	}
+
+	sc.streams += 1
+	if sc.streams < p.MaxStreams {
+		p.busy = append(p.busy, sc)
+	}
+	return sc.closer, nil
+}
+
+// Release gives back one stream on conn. Once its stream count drops to
+// zero, the connection moves to the idle list for future reuse or closing.
+func (p *StreamPool) Release(conn io.Closer) error {
+	p.Lock()
+	defer p.Unlock()
+
+	sc, ok := p.entries[conn]
+	if !ok {
+		return nil
+	}
+
+	wasSaturated := sc.streams >= p.MaxStreams
+	sc.streams -= 1
+
+	if sc.streams <= 0 {
+		sc.streams = 0
+		p.removeBusy(sc)
+		p.idle = append(p.idle, sc)
+	} else if wasSaturated {
+		p.busy = append(p.busy, sc)
+	}
+
+	return nil
+}
+
+// CloseClean discards conn entirely instead of returning it to the pool,
+// e.g. after a stream-level error that poisoned the whole connection.
+func (p *StreamPool) CloseClean(conn io.Closer) error {
+	p.Lock()
+	sc, ok := p.entries[conn]
+	if ok {
+		delete(p.entries, conn)
+		p.removeBusy(sc)
+		p.removeIdle(sc)
+	}
+	p.Unlock()
+
+	if conn != nil {
+		conn.Close()
+	}
+	return nil
+}
+
+func (p *StreamPool) removeBusy(sc *streamConn) {
+	for i, e := range p.busy {
+		if e == sc {
+			p.busy = append(p.busy[:i], p.busy[i+1:]...)
+			return
+		}
+	}
+}
+
+func (p *StreamPool) removeIdle(sc *streamConn) {
+	for i, e := range p.idle {
+		if e == sc {
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			return
+		}
+	}
+}
+
+// Destroy closes every pooled connection.
+func (p *StreamPool) Destroy() {
+	p.Lock()
+	defer p.Unlock()
+
+	for conn := range p.entries {
+		if conn != nil {
+			conn.Close()
+		}
+	}
+	p.entries = make(map[io.Closer]*streamConn)
+	p.idle = nil
+	p.busy = nil
+}
@@ -0,0 +1,70 @@
+package conn_pool
+
+import (
+	"io"
+	"testing"
+)
+
+func TestStreamPoolReusesUntilSaturated(t *testing.T) {
+	dials := 0
+	p := NewStreamPool(2)
+	p.New = func() (io.Closer, error) {
+		dials++
+		return &fakeConn{}, nil
+	}
+
+	c1, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	c2, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if c1 != c2 {
+		t.Fatalf("expected the second Get to reuse c1 while it has spare stream capacity")
+	}
+	if dials != 1 {
+		t.Fatalf("expected exactly one dial before saturation, got %d", dials)
+	}
+
+	c3, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if c3 == c1 {
+		t.Fatalf("expected a new conn once the first is saturated at MaxStreams")
+	}
+	if dials != 2 {
+		t.Fatalf("expected a second dial once saturated, got %d", dials)
+	}
+}
+
+func TestStreamPoolReleaseReturnsToIdleAtZero(t *testing.T) {
+	p := NewStreamPool(1)
+	p.New = func() (io.Closer, error) { return &fakeConn{}, nil }
+
+	c1, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := p.Release(c1); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+
+	dials := 0
+	p.New = func() (io.Closer, error) {
+		dials++
+		return &fakeConn{}, nil
+	}
+	c2, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if c2 != c1 {
+		t.Fatalf("expected Get to reuse the idle conn instead of dialing a new one")
+	}
+	if dials != 0 {
+		t.Fatalf("expected no new dial, the released conn should have been reused")
+	}
+}
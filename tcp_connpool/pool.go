@@ -1,16 +1,19 @@
 package tcp_connpool
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
-	MAX_CONN_ERROR = "Maximum connections reached"
-	LOG_TAG        = "[conn_pool]"
+	MAX_CONN_ERROR     = "Maximum connections reached"
+	POOL_TIMEOUT_ERROR = "timed out waiting for a free connection"
+	LOG_TAG            = "[conn_pool]"
 )
 
 type Error string
@@ -39,25 +42,66 @@ func EnableDebug(f bool) {
 }
 
 var ErrMaxConn = Error(MAX_CONN_ERROR)
-var m *sync.Mutex = new(sync.Mutex)
+var ErrPoolTimeout = Error(POOL_TIMEOUT_ERROR)
 
+// Options configures the pool-wait behavior of a TcpConnPool. With the zero
+// value, Get on a saturated pool waits (subject to its context) for a
+// connection to be released instead of returning ErrMaxConn.
+type Options struct {
+	// PoolTimeout is how long Get waits for a connection to be released
+	// before giving up with ErrPoolTimeout. Zero means wait indefinitely
+	// (subject to the context passed to Get).
+	PoolTimeout time.Duration
+
+	// PoolFIFO selects which idle connection Get reuses first. true
+	// reuses the oldest free connection in the pool (FIFO); false (the
+	// default) reuses the most recently released one (LIFO), which keeps
+	// fewer connections warm under light load.
+	PoolFIFO bool
+}
+
+// TcpConnPool pools net.Conn values for a single address. Unlike earlier
+// versions, every instance owns its own mutex: there is no package-level
+// lock, so pooling against many backends scales with the number of pools
+// instead of serializing every address through one global mutex.
 type TcpConnPool struct {
+	mu sync.Mutex
+
 	name         string
 	max_conns    int
 	max_idle     int
-	conns        int
 	dial_timeout int
-	free         []net.Conn
 	ping         Ping
+
+	pool_timeout time.Duration
+	pool_fifo    bool
+
+	conns    int32 // atomic: total open connections (idle + checked out)
+	timeouts uint64
+
+	free []net.Conn    // idle connections, guarded by mu
+	sem  chan struct{} // checkout slots, capacity max_conns
 }
 
+// NewTcpConnPool preserves the pool's pre-Options behavior of reusing the
+// oldest free connection first (FIFO); callers that want the newer LIFO
+// default should use NewTcpConnPoolWithOptions directly.
 func NewTcpConnPool(name string, max_conns int, max_idle int, dial_timeout int, ping Ping) *TcpConnPool {
+	return NewTcpConnPoolWithOptions(name, max_conns, max_idle, dial_timeout, ping, Options{PoolFIFO: true})
+}
+
+// NewTcpConnPoolWithOptions is like NewTcpConnPool but also configures
+// pool-wait behavior via opts.
+func NewTcpConnPoolWithOptions(name string, max_conns int, max_idle int, dial_timeout int, ping Ping, opts Options) *TcpConnPool {
 	return &TcpConnPool{
 		name:         name,
 		max_conns:    max_conns,
 		max_idle:     max_idle,
 		dial_timeout: dial_timeout,
 		ping:         ping,
+		pool_timeout: opts.PoolTimeout,
+		pool_fifo:    opts.PoolFIFO,
+		sem:          make(chan struct{}, max_conns),
 	}
 }
 
@@ -65,32 +109,81 @@ func (n *TcpConnPool) GetMaxConns() int {
 	return n.max_conns
 }
 
+func (n *TcpConnPool) GetTimeouts() uint64 {
+	return atomic.LoadUint64(&n.timeouts)
+}
+
 func (n *TcpConnPool) String() string {
+	n.mu.Lock()
+	free := len(n.free)
+	n.mu.Unlock()
 	return fmt.Sprintf("<TcpConnPool name:%s conns:%d free:%d max_conns:%d max_idle:%d>",
-		n.name, n.conns, len(n.free), n.max_conns, n.max_idle)
+		n.name, atomic.LoadInt32(&n.conns), free, n.max_conns, n.max_idle)
+}
+
+// waitTurn reserves one of max_conns checkout slots, blocking until one is
+// free, pool_timeout elapses, or ctx is done.
+func (n *TcpConnPool) waitTurn(ctx context.Context) error {
+	select {
+	case n.sem <- struct{}{}:
+		return nil
+	default:
+	}
+
+	var timeout <-chan time.Time
+	if n.pool_timeout > 0 {
+		timer := time.NewTimer(n.pool_timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case n.sem <- struct{}{}:
+		return nil
+	case <-timeout:
+		atomic.AddUint64(&n.timeouts, 1)
+		debug.Printf("%v pool wait timeout, pool %v", LOG_TAG, n)
+		return ErrPoolTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (n *TcpConnPool) freeTurn() {
+	select {
+	case <-n.sem:
+	default:
+	}
 }
 
 /**
  * n.conns means all connections
  */
-func (n *TcpConnPool) Get(timeout int64) (conn net.Conn, err error) {
-	m.Lock()
-	defer m.Unlock()
-
-	if n.conns >= n.max_conns && len(n.free) == 0 {
-		debug.Printf("%v max conn reached, pool %v", LOG_TAG, n)
-		return nil, ErrMaxConn
+func (n *TcpConnPool) Get(ctx context.Context, timeout int64) (conn net.Conn, err error) {
+	if err = n.waitTurn(ctx); err != nil {
+		return nil, err
 	}
 
 	new_conn := false
+	n.mu.Lock()
 	if len(n.free) > 0 {
-		// return the first free connection in the pool
-		conn = n.free[0]
-		n.free = n.free[1:]
+		if n.pool_fifo {
+			// return the oldest free connection in the pool (FIFO)
+			conn = n.free[0]
+			n.free = n.free[1:]
+		} else {
+			// return the most recently released connection (LIFO, default)
+			last := len(n.free) - 1
+			conn = n.free[last]
+			n.free = n.free[:last]
+		}
+		n.mu.Unlock()
 		debug.Printf("%v get exist conn %v, pool %v", LOG_TAG, conn, n)
 	} else {
+		n.mu.Unlock()
 		conn, err = n.open()
 		if err != nil {
+			n.freeTurn()
 			return nil, err
 		}
 		new_conn = true
@@ -104,14 +197,15 @@ func (n *TcpConnPool) Get(timeout int64) (conn net.Conn, err error) {
 
 	err = n.ping(conn)
 	if err != nil {
-		if !new_conn && n.conns > 0 {
-			n.conns -= 1
+		if !new_conn {
+			atomic.AddInt32(&n.conns, -1)
 		}
+		n.freeTurn()
 		conn.Close()
 		return nil, err
 	}
 	if new_conn {
-		n.conns += 1
+		atomic.AddInt32(&n.conns, 1)
 	}
 
 	return conn, nil
@@ -130,35 +224,38 @@ func (n *TcpConnPool) open() (conn net.Conn, err error) {
 }
 
 func (n *TcpConnPool) Close(conn net.Conn) error {
-	m.Lock()
-	defer m.Unlock()
+	defer n.freeTurn()
+
+	if conn == nil {
+		atomic.AddInt32(&n.conns, -1)
+		return nil
+	}
 
 	debug.Printf("%v return %v, pool %v", LOG_TAG, conn, n)
-	if conn != nil {
-		if len(n.free) >= n.max_idle {
-			debug.Printf("%v auto close %v, pool %v", LOG_TAG, conn, n)
-			conn.Close()
-			n.conns -= 1
-		} else {
-			n.free = append(n.free, conn)
-		}
+	n.mu.Lock()
+	if len(n.free) >= n.max_idle {
+		n.mu.Unlock()
+		debug.Printf("%v auto close %v, pool %v", LOG_TAG, conn, n)
+		conn.Close()
+		atomic.AddInt32(&n.conns, -1)
 	} else {
-		if n.conns > 0 {
-			n.conns -= 1
-		}
+		n.free = append(n.free, conn)
+		n.mu.Unlock()
 	}
 	return nil
 }
 
 func (n *TcpConnPool) Destroy() {
-	m.Lock()
-	defer m.Unlock()
+	n.mu.Lock()
+	free := n.free
+	n.free = nil
+	n.mu.Unlock()
 
-	for _, conn := range n.free {
+	for _, conn := range free {
 		if conn != nil {
 			debug.Printf("%v destroy %v, pool %v", LOG_TAG, conn, n)
 			conn.Close()
 		}
+		atomic.AddInt32(&n.conns, -1)
 	}
-	n = nil
 }
@@ -0,0 +1,66 @@
+package tcp_connpool
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+)
+
+// benchListener spins up a local TCP listener that just drains whatever it
+// receives, so benchmarks can dial real connections without touching the
+// network beyond loopback.
+func benchListener(b *testing.B) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatalf("listen: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				buf := make([]byte, 512)
+				for {
+					if _, err := c.Read(buf); err != nil {
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+	return ln
+}
+
+func noopPing(net.Conn) error { return nil }
+
+// BenchmarkPoolGetPut drives concurrent Get/Close cycles against a single
+// pool for each of a range of pool sizes, measuring contention on that
+// pool's own mutex and checkout slots.
+func BenchmarkPoolGetPut(b *testing.B) {
+	for _, size := range []int{1, 8, 64, 256} {
+		size := size
+		b.Run(fmt.Sprintf("pool-%d", size), func(b *testing.B) {
+			ln := benchListener(b)
+			defer ln.Close()
+
+			pool := NewTcpConnPool(ln.Addr().String(), size, size, 1000, noopPing)
+			defer pool.Destroy()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				ctx := context.Background()
+				for pb.Next() {
+					conn, err := pool.Get(ctx, 0)
+					if err != nil {
+						b.Fatal(err)
+					}
+					pool.Close(conn)
+				}
+			})
+		})
+	}
+}
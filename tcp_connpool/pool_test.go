@@ -0,0 +1,207 @@
+package tcp_connpool
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testListener spins up a local TCP listener that just drains whatever it
+// receives, so tests can dial real connections without touching the
+// network beyond loopback.
+func testListener(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				buf := make([]byte, 512)
+				for {
+					if _, err := c.Read(buf); err != nil {
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+	return ln
+}
+
+func TestGetPoolFIFOOptionIsFIFO(t *testing.T) {
+	ln := testListener(t)
+	defer ln.Close()
+
+	pool := NewTcpConnPoolWithOptions(ln.Addr().String(), 2, 2, 1000, noopPing, Options{PoolFIFO: true})
+	defer pool.Destroy()
+
+	c1, err := pool.Get(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Get c1: %v", err)
+	}
+	c2, err := pool.Get(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Get c2: %v", err)
+	}
+	pool.Close(c1)
+	pool.Close(c2)
+
+	got, err := pool.Get(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got != c1 {
+		t.Fatalf("PoolFIFO=true should reuse the oldest conn (c1) first")
+	}
+}
+
+func TestGetDefaultIsLIFO(t *testing.T) {
+	ln := testListener(t)
+	defer ln.Close()
+
+	pool := NewTcpConnPoolWithOptions(ln.Addr().String(), 2, 2, 1000, noopPing, Options{})
+	defer pool.Destroy()
+
+	c1, err := pool.Get(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Get c1: %v", err)
+	}
+	c2, err := pool.Get(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Get c2: %v", err)
+	}
+	pool.Close(c1)
+	pool.Close(c2)
+
+	got, err := pool.Get(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got != c2 {
+		t.Fatalf("default PoolFIFO=false should reuse the most recently released conn (c2) first")
+	}
+}
+
+func TestNewTcpConnPoolLegacyConstructorIsFIFO(t *testing.T) {
+	ln := testListener(t)
+	defer ln.Close()
+
+	pool := NewTcpConnPool(ln.Addr().String(), 2, 2, 1000, noopPing)
+	defer pool.Destroy()
+
+	c1, err := pool.Get(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Get c1: %v", err)
+	}
+	c2, err := pool.Get(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Get c2: %v", err)
+	}
+	pool.Close(c1)
+	pool.Close(c2)
+
+	got, err := pool.Get(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got != c1 {
+		t.Fatalf("NewTcpConnPool must preserve the legacy FIFO behavior and reuse the oldest conn (c1) first")
+	}
+}
+
+func TestGetPoolTimeoutReturnsErrPoolTimeout(t *testing.T) {
+	ln := testListener(t)
+	defer ln.Close()
+
+	pool := NewTcpConnPoolWithOptions(ln.Addr().String(), 1, 1, 1000, noopPing, Options{PoolTimeout: 20 * time.Millisecond})
+	defer pool.Destroy()
+
+	if _, err := pool.Get(context.Background(), 0); err != nil {
+		t.Fatalf("first Get should succeed: %v", err)
+	}
+
+	_, err := pool.Get(context.Background(), 0)
+	if err != ErrPoolTimeout {
+		t.Fatalf("expected ErrPoolTimeout on a saturated pool, got %v", err)
+	}
+	if pool.GetTimeouts() != 1 {
+		t.Fatalf("expected GetTimeouts() to record the timeout")
+	}
+}
+
+// TestCloseFreesSemSlotForNextGet exercises the sem accounting across a
+// Get/Close cycle: releasing a conn must free its checkout slot so the next
+// Get on a saturated pool succeeds instead of blocking.
+func TestCloseFreesSemSlotForNextGet(t *testing.T) {
+	ln := testListener(t)
+	defer ln.Close()
+
+	pool := NewTcpConnPool(ln.Addr().String(), 1, 1, 1000, noopPing)
+	defer pool.Destroy()
+
+	conn, err := pool.Get(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := pool.Close(conn); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := pool.Get(context.Background(), 0)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Get after Close should succeed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Get after Close did not return; sem slot was not freed")
+	}
+}
+
+// TestDestroyClosesFreeConnsAndResetsConns drains the idle free list and
+// asserts the atomic conns counter is decremented for each one, so a fresh
+// Get after Destroy dials rather than reusing a closed conn.
+func TestDestroyClosesFreeConnsAndResetsConns(t *testing.T) {
+	ln := testListener(t)
+	defer ln.Close()
+
+	pool := NewTcpConnPool(ln.Addr().String(), 2, 2, 1000, noopPing)
+
+	c1, err := pool.Get(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := pool.Close(c1); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	pool.Destroy()
+
+	if n := atomic.LoadInt32(&pool.conns); n != 0 {
+		t.Fatalf("expected conns==0 after Destroy, got %d", n)
+	}
+	if n := pool.String(); n == "" {
+		t.Fatalf("String should still report pool state after Destroy")
+	}
+
+	conn, err := pool.Get(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Get after Destroy: %v", err)
+	}
+	if conn == c1 {
+		t.Fatalf("Get after Destroy should dial a fresh conn, not reuse the destroyed one")
+	}
+}